@@ -2,13 +2,20 @@ package elasticsearch
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 
@@ -20,49 +27,113 @@ import (
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
+// minRawClientVersion is the first Elasticsearch major release that the
+// bundled github.com/olivere/elastic client can no longer talk to. Releases
+// at or above this version (and any OpenSearch cluster, whatever version it
+// reports) are driven through a lightweight internal HTTP client instead.
+const minRawClientVersion = 8
+
 type Elasticsearch struct {
 	AuthBearerToken     string          `toml:"auth_bearer_token"`
+	BulkActions         int             `toml:"bulk_actions"`
+	BulkSize            int             `toml:"bulk_size"`
+	ClientVersion       string          `toml:"client_version"`
+	ComponentTemplates  []string        `toml:"component_templates"`
+	DeadLetterIndex     string          `toml:"dead_letter_index"`
 	DefaultPipeline     string          `toml:"default_pipeline"`
 	DefaultTagValue     string          `toml:"default_tag_value"`
 	EnableGzip          bool            `toml:"enable_gzip"`
 	EnableSniffer       bool            `toml:"enable_sniffer"`
+	FlattenFields       bool            `toml:"flatten_fields"`
+	FlattenSeparator    string          `toml:"flatten_separator"`
 	FloatHandling       string          `toml:"float_handling"`
 	FloatReplacement    float64         `toml:"float_replacement_value"`
+	FlushInterval       config.Duration `toml:"flush_interval"`
 	ForceDocumentID     bool            `toml:"force_document_id"`
 	HealthCheckInterval config.Duration `toml:"health_check_interval"`
 	HealthCheckTimeout  config.Duration `toml:"health_check_timeout"`
+	ILMMaxAge           string          `toml:"ilm_max_age"`
+	ILMMaxSize          string          `toml:"ilm_max_size"`
+	ILMMinAgeDelete     string          `toml:"ilm_min_age_delete"`
+	ILMPolicy           string          `toml:"ilm_policy"`
+	ILMPolicyName       string          `toml:"ilm_policy_name"`
 	IndexName           string          `toml:"index_name"`
 	ManageTemplate      bool            `toml:"manage_template"`
+	MaxRetries          int             `toml:"max_retries"`
 	OverwriteTemplate   bool            `toml:"overwrite_template"`
 	Password            string          `toml:"password"`
 	TemplateName        string          `toml:"template_name"`
+	TemplateType        string          `toml:"template_type"`
 	Timeout             config.Duration `toml:"timeout"`
 	URLs                []string        `toml:"urls"`
+	UseDataStream       bool            `toml:"use_data_stream"`
 	UsePipeline         string          `toml:"use_pipeline"`
 	Username            string          `toml:"username"`
+	Workers             int             `toml:"workers"`
 	Log                 telegraf.Logger `toml:"-"`
 	majorReleaseNumber  int
 	pipelineName        string
 	pipelineTagKeys     []string
 	tagKeys             []string
+	processor           *bulkProcessor
+
+	// bulkFailureStats holds one selfstat counter per distinct per-item
+	// bulk error type (e.g. "mapper_parsing_exception"), registered lazily
+	// since the set of reasons is only known once the cluster starts
+	// reporting them.
+	bulkFailureStats   map[string]selfstat.Stat
+	bulkFailureStatsMu sync.Mutex
+
+	// failureLogMu/lastFailureLog/suppressedFailureLogs rate-limit the
+	// per-item failure log line to at most one per failureLogInterval, so a
+	// bad mapping on a high-volume measurement doesn't flood the log.
+	failureLogMu          sync.Mutex
+	lastFailureLog        time.Time
+	suppressedFailureLogs int
+
 	tls.ClientConfig
 
+	// useRawClient is set once Connect() detects (or is told via
+	// ClientVersion) that the cluster is at or beyond minRawClientVersion,
+	// or that it is OpenSearch. In that mode Client is left nil and the
+	// legacy manageTemplate() is replaced by manageTemplateRaw().
+	// httpClient and baseURL are populated regardless of useRawClient, since
+	// the bulk write path and some APIs (ILM, data streams,
+	// composable/component templates) always go over raw HTTP.
+	useRawClient bool
+	httpClient   *http.Client
+	baseURL      string
+
+	// baseURLs holds every configured URL with its trailing slash trimmed;
+	// baseURL is always baseURLs[0] and is used for the one-off template/ILM
+	// management calls made in Connect(). The recurring write path
+	// (sendBulk, sendToDeadLetter) round-robins across baseURLs via
+	// nextBaseURL so every configured URL, not just the first, carries
+	// write traffic.
+	baseURLs []string
+	urlIdx   uint64
+
 	Client *elastic.Client
 }
 
 var sampleConfig = `
   ## The full HTTP endpoint URL for your Elasticsearch instance
-  ## Multiple urls can be specified as part of the same cluster,
-  ## this means that only ONE of the urls will be written to each interval.
+  ## Multiple urls can be specified as part of the same cluster; bulk writes
+  ## are round-robined across all of them.
   urls = [ "http://node1.es.example.com:9200" ] # required.
   ## Elasticsearch client timeout, defaults to "5s" if not set.
   timeout = "5s"
   ## Set to true to ask Elasticsearch a list of all cluster nodes,
   ## thus it is not necessary to list all nodes in the urls config option.
+  ## Only applies to legacy template management, which still goes through
+  ## the bundled github.com/olivere/elastic client on clusters below version
+  ## 8; ILM, data stream and composable/component template management and
+  ## the bulk write path use urls directly instead of sniffing.
   enable_sniffer = false
-  ## Set to true to enable gzip compression
+  ## Set to true to enable gzip compression of bulk write request bodies.
   enable_gzip = false
   ## Set the interval to check if the Elasticsearch nodes are available
   ## Setting to "0s" will disable the health check (not recommended in production)
@@ -75,6 +146,15 @@ var sampleConfig = `
   ## HTTP bearer token authentication details
   # auth_bearer_token = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"
 
+  ## Elasticsearch client compatibility
+  ## By default the major version is auto-detected from the cluster's root
+  ## endpoint response. Set this to force a specific major version (e.g. "8")
+  ## for clusters that do not answer the detection request the way Telegraf
+  ## expects, such as some OpenSearch deployments. Versions 8 and above (and
+  ## their OpenSearch equivalents) are driven through an internal HTTP client
+  ## instead of github.com/olivere/elastic, which does not support them.
+  # client_version = ""
+
   ## Index Config
   ## The target index for metrics (Elasticsearch will create if it not exists).
   ## You can use the date specifiers below to create indexes per time frame.
@@ -110,6 +190,21 @@ var sampleConfig = `
   ## If set to true a unique ID hash will be sent as sha256(concat(timestamp,measurement,series-hash)) string
   ## it will enable data resend and update metric points avoiding duplicated metrics with diferent id's
   force_document_id = false
+  ## Controls how the managed template is installed.
+  ##    legacy     -- use the deprecated single "_template" API (default, for
+  ##                  back-compat); falls back to "composable" automatically
+  ##                  against clusters where that API is unavailable.
+  ##    composable -- install a single ES 7.8+ "_index_template".
+  ##    component  -- install telegraf's mappings and settings as separate
+  ##                  "_component_template"s and compose them, along with
+  ##                  any names listed in component_templates, into the
+  ##                  final "_index_template".
+  # template_type = "legacy"
+  ## Names of additional, user-managed component templates to compose
+  ## together with telegraf's own mappings/settings components. Only used
+  ## when template_type = "component". Telegraf's components are composed
+  ## last so they layer their mappings on top of these.
+  # component_templates = []
 
   ## Specifies the handling of NaN and Inf values.
   ## This option can have the following values:
@@ -120,6 +215,37 @@ var sampleConfig = `
   # float_handling = "none"
   # float_replacement_value = 0.0
 
+  ## Set to true to emit documents as flat key/value pairs instead of
+  ## nesting fields and tags under the measurement name, e.g.
+  ## {"@timestamp":..., "measurement_name":"cpu", "tag.host":"x",
+  ## "cpu.usage_idle": 99.1} rather than {"tag": {"host":"x"},
+  ## "cpu": {"usage_idle": 99.1}}. This keeps mapping.total_fields.limit
+  ## under control and simplifies querying in Kibana/Discover and Lens.
+  # flatten_fields = false
+  ## Separator placed between the measurement/tag prefix and the field or
+  ## tag name when flatten_fields is enabled.
+  # flatten_separator = "."
+
+  ## Data Stream Config
+  ## Set to true to write metrics into an Elasticsearch data stream instead
+  ## of a rolling date-suffixed index. When enabled, index_name is cut off
+  ## at its first %-date specifier and the rest is used as the data stream
+  ## name (e.g. "telegraf-%Y.%m.%d" becomes "telegraf-"), and telegraf
+  ## installs a matching ILM policy and composable index template on
+  ## Connect().
+  # use_data_stream = false
+  ## Name of the ILM policy to create/update and attach to the data stream.
+  # ilm_policy_name = "telegraf"
+  ## Inline JSON or a path to a file containing the ILM policy to install.
+  ## If unset, a default hot/delete policy is built from the rollover and
+  ## deletion settings below.
+  # ilm_policy = ""
+  ## Default ILM policy rollover/deletion settings, used when ilm_policy is
+  ## not set.
+  # ilm_max_age = "30d"
+  # ilm_max_size = "50gb"
+  # ilm_min_age_delete = "90d"
+
   ## Pipeline Config
   ## To use a ingest pipeline, set this to the name of the pipeline you want to use.
   # use_pipeline = "my_pipeline"
@@ -129,6 +255,32 @@ var sampleConfig = `
   ## no pipeline is used for the metric.
   # use_pipeline = "{{es_pipeline}}"
   # default_pipeline = "my_pipeline"
+
+  ## Bulk Processor Config
+  ## Metrics are queued into an internal bulk processor that batches across
+  ## Telegraf flushes and sends them asynchronously, instead of blocking the
+  ## agent's flush loop on a single "_bulk" call. A batch is flushed once it
+  ## reaches bulk_actions documents, once it reaches bulk_size bytes
+  ## (whichever comes first), or every flush_interval, whichever is sooner.
+  # bulk_actions = 1000
+  ## Maximum size in bytes of a single bulk request body. 0 disables the
+  ## size-based trigger and leaves bulk_actions/flush_interval in control.
+  # bulk_size = 0
+  ## Upper bound on how long documents sit buffered before being flushed.
+  # flush_interval = "10s"
+  ## Number of batches that may be in flight to Elasticsearch at once.
+  # workers = 1
+  ## Number of times a batch is retried, with exponential backoff, after a
+  ## retriable failure (HTTP 429, 5xx, or a transport error). Non-retriable
+  ## per-item failures are not affected by this setting.
+  # max_retries = 3
+
+  ## Optional index to route documents to when a bulk item fails with a
+  ## non-retriable error (e.g. mapper_parsing_exception) or exhausts its
+  ## retries, instead of silently dropping the metric. The original document
+  ## is stored alongside the Elasticsearch error that rejected it. Supports
+  ## the same date specifiers as index_name.
+  # dead_letter_index = "telegraf-dlq-%Y.%m.%d"
 `
 
 const telegrafTemplate = `
@@ -194,6 +346,7 @@ const telegrafTemplate = `
 					}
 				}
 			}
+			{{.ExtraDynamicTemplates}}
 		]
 		{{ if (lt .Version 7) }}
 		}
@@ -202,8 +355,9 @@ const telegrafTemplate = `
 }`
 
 type templatePart struct {
-	TemplatePattern string
-	Version         int
+	TemplatePattern       string
+	Version               int
+	ExtraDynamicTemplates string
 }
 
 func (a *Elasticsearch) Connect() error {
@@ -274,32 +428,66 @@ func (a *Elasticsearch) Connect() error {
 		a.Log.Debugf("Disabling health check")
 	}
 
-	client, err := elastic.NewClient(clientOptions...)
-
+	// Determine the cluster's major release number using a plain HTTP
+	// request to the root endpoint rather than the olivere/elastic client,
+	// since that client rejects the version string returned by
+	// Elasticsearch/OpenSearch releases at or above minRawClientVersion.
+	majorReleaseNumber, isOpenSearch, err := a.detectMajorReleaseNumber(ctx, httpclient)
 	if err != nil {
 		return err
 	}
 
-	// check for ES version on first node
-	esVersion, err := client.ElasticsearchVersion(a.URLs[0])
-
-	if err != nil {
-		return fmt.Errorf("elasticsearch version check failed: %s", err)
+	a.majorReleaseNumber = majorReleaseNumber
+	// OpenSearch is never routed through github.com/olivere/elastic, even
+	// when its reported version number is below minRawClientVersion, since
+	// that client does not support OpenSearch's API dialect.
+	a.useRawClient = majorReleaseNumber >= minRawClientVersion || isOpenSearch
+	a.httpClient = httpclient
+	a.baseURLs = make([]string, len(a.URLs))
+	for i, u := range a.URLs {
+		a.baseURLs[i] = strings.TrimRight(u, "/")
 	}
+	a.baseURL = a.baseURLs[0]
 
-	// quit if ES version is not supported
-	majorReleaseNumber, err := strconv.Atoi(strings.Split(esVersion, ".")[0])
-	if err != nil || majorReleaseNumber < 5 {
-		return fmt.Errorf("elasticsearch version not supported: %s", esVersion)
+	if !a.useRawClient {
+		client, err := elastic.NewClient(clientOptions...)
+		if err != nil {
+			return err
+		}
+		a.Client = client
 	}
 
-	a.Log.Infof("Elasticsearch version: %q", esVersion)
-
-	a.Client = client
-	a.majorReleaseNumber = majorReleaseNumber
+	if a.UseDataStream {
+		// Data streams are addressed by a single, static name: there is no
+		// date-suffixed rolling index to re-evaluate per write, so
+		// index_name is cut off at its first date specifier here rather
+		// than expanded in GetIndexName.
+		if idx := strings.IndexAny(a.IndexName, "%"); idx >= 0 {
+			a.IndexName = a.IndexName[:idx]
+		}
+		if a.ForceDocumentID {
+			a.Log.Warnf("force_document_id is ignored when use_data_stream is enabled: " +
+				"data streams only accept the \"create\" action, which rejects an explicit _id")
+		}
+	}
 
 	if a.ManageTemplate {
-		err := a.manageTemplate(ctx)
+		var err error
+		switch {
+		case a.UseDataStream:
+			err = a.manageDataStream(ctx)
+		case a.TemplateType == "component":
+			err = a.manageComponentTemplates(ctx)
+		case a.TemplateType == "composable" || a.useRawClient:
+			// The legacy "_template" API used by manageTemplate() is not
+			// available against clusters at or above minRawClientVersion,
+			// so template_type = "legacy" falls back to composable there.
+			err = a.manageTemplateRaw(ctx)
+		case a.TemplateType == "" || a.TemplateType == "legacy":
+			err = a.manageTemplate(ctx)
+		default:
+			err = fmt.Errorf("invalid template_type: %s", a.TemplateType)
+		}
 		if err != nil {
 			return err
 		}
@@ -308,9 +496,79 @@ func (a *Elasticsearch) Connect() error {
 	a.IndexName, a.tagKeys = a.GetTagKeys(a.IndexName)
 	a.pipelineName, a.pipelineTagKeys = a.GetTagKeys(a.UsePipeline)
 
+	a.processor = newBulkProcessor(a)
+
 	return nil
 }
 
+// esRootResponse models the subset of the Elasticsearch (and OpenSearch)
+// root endpoint ("GET /") response that is needed to determine the major
+// release number being talked to.
+type esRootResponse struct {
+	Version struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+// detectMajorReleaseNumber returns the ClientVersion override if one was
+// configured, otherwise it queries the cluster's root endpoint directly and
+// parses the reported version number. The returned bool reports whether the
+// cluster identified itself as OpenSearch: OpenSearch 1.x/2.x report a
+// "version.number" of "1"/"2", which is below minRawClientVersion, but
+// github.com/olivere/elastic cannot talk to OpenSearch regardless of that
+// number, so OpenSearch always forces the raw client path.
+func (a *Elasticsearch) detectMajorReleaseNumber(ctx context.Context, httpclient *http.Client) (int, bool, error) {
+	if a.ClientVersion != "" {
+		majorReleaseNumber, err := strconv.Atoi(strings.Split(a.ClientVersion, ".")[0])
+		if err != nil || majorReleaseNumber < 5 {
+			return 0, false, fmt.Errorf("invalid client_version: %s", a.ClientVersion)
+		}
+		return majorReleaseNumber, false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(a.URLs[0], "/")+"/", nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("building elasticsearch version request failed: %s", err)
+	}
+	if a.Username != "" && a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.AuthBearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.AuthBearerToken)
+	}
+
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("elasticsearch version check failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, false, fmt.Errorf("elasticsearch version check failed: %s", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, false, fmt.Errorf("elasticsearch version check failed: server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var root esRootResponse
+	if err := json.Unmarshal(body, &root); err != nil {
+		return 0, false, fmt.Errorf("elasticsearch version check failed: %s", err)
+	}
+
+	majorReleaseNumber, err := strconv.Atoi(strings.Split(root.Version.Number, ".")[0])
+	isOpenSearch := root.Version.Distribution == "opensearch"
+	if err != nil || (majorReleaseNumber < 5 && !isOpenSearch) {
+		return 0, false, fmt.Errorf("elasticsearch version not supported: %s", root.Version.Number)
+	}
+
+	a.Log.Infof("Elasticsearch version: %q, distribution: %q", root.Version.Number, root.Version.Distribution)
+
+	return majorReleaseNumber, isOpenSearch, nil
+}
+
 // GetPointID generates a unique ID for a Metric Point
 func GetPointID(m telegraf.Metric) string {
 	var buffer bytes.Buffer
@@ -323,84 +581,707 @@ func GetPointID(m telegraf.Metric) string {
 	return fmt.Sprintf("%x", sha256.Sum256(buffer.Bytes()))
 }
 
+// Write enqueues each metric's bulk action+document pair onto the
+// asynchronous bulk processor and returns without waiting for them to be
+// sent; the processor batches and sends them in the background. The queue
+// holds bulk_actions*workers items, and Write blocks while it is full, so a
+// backed-up cluster stalls the agent's flush loop only once that many
+// documents are waiting. Enqueuing only fails if a document cannot be
+// marshalled, which does not depend on the state of the cluster.
 func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
-	if len(metrics) == 0 {
-		return nil
+	for _, metric := range metrics {
+		item, err := a.marshalBulkItem(metric)
+		if err != nil {
+			return fmt.Errorf("error marshalling document for Elasticsearch: %s", err)
+		}
+		a.processor.enqueue(item)
 	}
 
-	bulkRequest := a.Client.Bulk()
+	return nil
+}
 
-	for _, metric := range metrics {
-		var name = metric.Name()
-
-		// index name has to be re-evaluated each time for telegraf
-		// to send the metric to the correct time-based index
-		indexName := a.GetIndexName(a.IndexName, metric.Time(), a.tagKeys, metric.Tags())
-
-		// Handle NaN and inf field-values
-		fields := make(map[string]interface{})
-		for k, value := range metric.Fields() {
-			v, ok := value.(float64)
-			if !ok || a.FloatHandling == "none" || !(math.IsNaN(v) || math.IsInf(v, 0)) {
-				fields[k] = value
-				continue
-			}
-			if a.FloatHandling == "drop" {
-				continue
+// bulkItem is a single document queued onto the bulk processor: its "_bulk"
+// NDJSON lines plus the metadata needed to report or dead-letter it if the
+// cluster rejects it.
+type bulkItem struct {
+	lines      []byte
+	doc        []byte
+	metricName string
+	indexName  string
+	retries    int
+}
+
+// marshalBulkItem renders a single metric as its two-line "_bulk" NDJSON
+// entry (the action/metadata line followed by the document line).
+func (a *Elasticsearch) marshalBulkItem(metric telegraf.Metric) (bulkItem, error) {
+	indexName := a.GetIndexName(a.IndexName, metric.Time(), a.tagKeys, metric.Tags())
+
+	// Data streams only accept the "create" action; a rolling index can use
+	// either, so "index" is kept there to allow ID-based upserts.
+	actionName := "index"
+	if a.UseDataStream {
+		actionName = "create"
+	}
+
+	actionMeta := map[string]interface{}{
+		"_index": indexName,
+	}
+
+	// Elasticsearch rejects a "create" carrying an explicit _id, so
+	// force_document_id is ignored for data streams (warned about once in
+	// Connect()) rather than sending requests that are guaranteed to fail.
+	if a.ForceDocumentID && !a.UseDataStream {
+		actionMeta["_id"] = GetPointID(metric)
+	}
+
+	if a.majorReleaseNumber <= 6 {
+		actionMeta["_type"] = "metrics"
+	}
+
+	if a.UsePipeline != "" {
+		if pipelineName := a.getPipelineName(a.pipelineName, a.pipelineTagKeys, metric.Tags()); pipelineName != "" {
+			actionMeta["pipeline"] = pipelineName
+		}
+	}
+
+	action := map[string]interface{}{actionName: actionMeta}
+
+	actionLine, err := json.Marshal(action)
+	if err != nil {
+		return bulkItem{}, err
+	}
+	docLine, err := json.Marshal(a.buildDoc(metric))
+	if err != nil {
+		return bulkItem{}, err
+	}
+
+	var lines bytes.Buffer
+	lines.Write(actionLine)
+	lines.WriteByte('\n')
+	lines.Write(docLine)
+	lines.WriteByte('\n')
+
+	return bulkItem{
+		lines:      lines.Bytes(),
+		doc:        docLine,
+		metricName: metric.Name(),
+		indexName:  indexName,
+	}, nil
+}
+
+const (
+	defaultBulkActions   = 1000
+	defaultFlushInterval = 10 * time.Second
+	defaultWorkers       = 1
+	defaultMaxRetries    = 3
+	initialRetryBackoff  = 500 * time.Millisecond
+)
+
+// bulkProcessor batches the NDJSON bulk items enqueued by Write() across
+// Telegraf flushes and sends them to the cluster in the background, modelled
+// on github.com/olivere/elastic's own BulkProcessor (which needs the olivere
+// Client, and that client is not created when useRawClient is set). A batch
+// is sent
+// once it reaches bulkActions items, once it reaches bulkSize bytes, or
+// every flushInterval, whichever comes first. Up to workers batches may be
+// in flight at once.
+//
+// Per-item retries (see handleBulkResult) are queued separately from items,
+// in retries/retriesMu rather than onto the items channel itself. items is
+// bounded and is only ever drained by run(), so a worker goroutine spawned
+// by flush() that re-enqueued a retry by sending on items could deadlock:
+// run() can be blocked handing a full batch to flush() (which itself blocks
+// until a worker slot frees up via sem) while every worker is blocked
+// sending its retry into a full items channel, and nothing is left to drain
+// it. retries is an unbounded, mutex-guarded queue instead, so queuing a
+// retry can never block a worker, and done lets retryEnqueue refuse new work
+// once stop() has started instead of sending on a channel stop() closes.
+// retryEnqueue also holds a retried item back for itemRetryBackoff(retries)
+// before it is added to retries, so per-item retries back off under
+// sustained backpressure the same way sendBulkWithRetry's whole-batch
+// retries do.
+type bulkProcessor struct {
+	a     *Elasticsearch
+	items chan bulkItem
+	sem   chan struct{}
+	wg    sync.WaitGroup
+
+	retriesMu sync.Mutex
+	retries   []bulkItem
+	notify    chan struct{}
+	done      chan struct{}
+}
+
+func newBulkProcessor(a *Elasticsearch) *bulkProcessor {
+	bulkActions := a.BulkActions
+	if bulkActions <= 0 {
+		bulkActions = defaultBulkActions
+	}
+	workers := a.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	bp := &bulkProcessor{
+		a:      a,
+		items:  make(chan bulkItem, bulkActions*workers),
+		sem:    make(chan struct{}, workers),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	bp.wg.Add(1)
+	go bp.run()
+
+	return bp
+}
+
+func (bp *bulkProcessor) enqueue(item bulkItem) {
+	bp.items <- item
+}
+
+// itemRetryBackoff returns how long to wait before a per-item retry is
+// eligible to be resubmitted, doubling with each attempt just like
+// sendBulkWithRetry's whole-batch backoff, so a retried item does not simply
+// ride the very next flush under sustained backpressure.
+func itemRetryBackoff(retries int) time.Duration {
+	if retries <= 0 {
+		return 0
+	}
+	backoff := initialRetryBackoff
+	for i := 1; i < retries; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// retryEnqueue queues item for resubmission once its backoff elapses,
+// without ever blocking the calling worker, and reports whether it was
+// accepted: once stop() has begun, done is closed and retryEnqueue refuses
+// the item instead of adding it to a queue that may no longer be drained.
+func (bp *bulkProcessor) retryEnqueue(item bulkItem) bool {
+	select {
+	case <-bp.done:
+		return false
+	default:
+	}
+
+	delay := itemRetryBackoff(item.retries)
+
+	bp.wg.Add(1)
+	go func() {
+		defer bp.wg.Done()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-bp.done:
+				// Shutdown started mid-backoff: fall through and queue the
+				// item anyway so stop()'s final drain can dead-letter it
+				// instead of it vanishing without a trace.
 			}
+		}
+
+		bp.retriesMu.Lock()
+		bp.retries = append(bp.retries, item)
+		bp.retriesMu.Unlock()
+
+		select {
+		case bp.notify <- struct{}{}:
+		default:
+		}
+	}()
+	return true
+}
+
+// drainRetries moves every currently queued retry into batch/batchSize.
+func (bp *bulkProcessor) drainRetries(batch *[]bulkItem, batchSize *int) {
+	bp.retriesMu.Lock()
+	pending := bp.retries
+	bp.retries = nil
+	bp.retriesMu.Unlock()
+
+	for _, item := range pending {
+		*batch = append(*batch, item)
+		*batchSize += len(item.lines)
+	}
+}
+
+// stop closes the item queue and blocks until every buffered item has been
+// flushed and every worker and pending retry has completed. Once done is
+// closed, retries are no longer sent: a retriable failure reported after
+// that is refused by retryEnqueue and handled by handleBulkResult, and a
+// retry queued after run()'s final flush (for example one whose backoff
+// stop() cut short) is dead-lettered best-effort here, or logged as dropped
+// when no dead_letter_index is set.
+func (bp *bulkProcessor) stop() {
+	close(bp.done)
+	close(bp.items)
+	bp.wg.Wait()
+
+	bp.retriesMu.Lock()
+	leftover := bp.retries
+	bp.retries = nil
+	bp.retriesMu.Unlock()
+
+	for _, item := range leftover {
+		if bp.a.DeadLetterIndex != "" {
+			bp.a.sendToDeadLetter(item, 0, "shutdown", "bulk processor stopped before the retry could be sent")
+			continue
+		}
+		bp.a.Log.Errorf("Elasticsearch dropped metric %s on shutdown before it could be retried", item.metricName)
+	}
+}
+
+func (bp *bulkProcessor) run() {
+	defer bp.wg.Done()
+
+	a := bp.a
+
+	flushInterval := time.Duration(a.FlushInterval)
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	bulkActions := a.BulkActions
+	if bulkActions <= 0 {
+		bulkActions = defaultBulkActions
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []bulkItem
+	batchSize := 0
 
-			if math.IsNaN(v) || math.IsInf(v, 1) {
-				fields[k] = a.FloatReplacement
-			} else {
-				fields[k] = -a.FloatReplacement
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		toSend := batch
+		batch = nil
+		batchSize = 0
+
+		bp.sem <- struct{}{}
+		bp.wg.Add(1)
+		go func() {
+			defer bp.wg.Done()
+			defer func() { <-bp.sem }()
+			a.sendBulkWithRetry(toSend)
+		}()
+	}
+
+	for {
+		select {
+		case item, ok := <-bp.items:
+			if !ok {
+				// Pull in anything still queued for retry so it rides along
+				// on the final flush instead of being abandoned in the queue.
+				bp.drainRetries(&batch, &batchSize)
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			batchSize += len(item.lines)
+			if len(batch) >= bulkActions || (a.BulkSize > 0 && batchSize >= a.BulkSize) {
+				flush()
 			}
+		case <-bp.notify:
+			bp.drainRetries(&batch, &batchSize)
+			if len(batch) >= bulkActions || (a.BulkSize > 0 && batchSize >= a.BulkSize) {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
 		}
+	}
+}
 
-		m := make(map[string]interface{})
+// bulkHTTPError is returned by sendBulk when the "_bulk" call itself fails
+// at the transport/status-code level, as opposed to a per-item failure
+// reported inside a 2xx response body.
+type bulkHTTPError struct {
+	StatusCode int
+	Body       string
+}
 
-		m["@timestamp"] = metric.Time()
-		m["measurement_name"] = name
-		m["tag"] = metric.Tags()
-		m[name] = fields
+func (e *bulkHTTPError) Error() string {
+	return fmt.Sprintf("elasticsearch bulk request failed with status %d: %s", e.StatusCode, e.Body)
+}
 
-		br := elastic.NewBulkIndexRequest().Index(indexName).Doc(m)
+// isRetriableBulkError reports whether a failed "_bulk" call is worth
+// retrying: HTTP 429 (es_rejected_execution_exception) and 5xx responses
+// are transient, as is any non-HTTP error, which is almost always a
+// transport-level problem (connection reset, timeout, DNS, ...).
+func isRetriableBulkError(err error) bool {
+	var httpErr *bulkHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	return true
+}
 
-		if a.ForceDocumentID {
-			id := GetPointID(metric)
-			br.Id(id)
+// sendBulkWithRetry sends a batch to the cluster, retrying the whole batch
+// on retriable failures with exponential backoff up to max_retries times. It
+// never returns an error: Write() has already returned by the time a batch
+// is sent, so whole-batch failures that survive every retry are only
+// logged, while per-item failures in a successful response are handled by
+// handleBulkResult.
+func (a *Elasticsearch) sendBulkWithRetry(batch []bulkItem) {
+	maxRetries := a.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	var body bytes.Buffer
+	for _, item := range batch {
+		body.Write(item.lines)
+	}
+	payload := body.Bytes()
+
+	backoff := initialRetryBackoff
+	for attempt := 0; ; attempt++ {
+		result, err := a.sendBulk(payload)
+		if err == nil {
+			a.handleBulkResult(batch, result)
+			return
 		}
 
-		if a.majorReleaseNumber <= 6 {
-			br.Type("metrics")
+		if attempt >= maxRetries || !isRetriableBulkError(err) {
+			a.Log.Errorf("Elasticsearch bulk request of %d metrics failed permanently after %d attempt(s): %s", len(batch), attempt+1, err)
+			return
+		}
+
+		a.Log.Errorf("Elasticsearch bulk request of %d metrics failed (attempt %d/%d), retrying in %s: %s", len(batch), attempt+1, maxRetries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// nextBaseURL round-robins across every configured URL so write traffic
+// (sendBulk, sendToDeadLetter) is distributed across the whole cluster
+// rather than only ever landing on URLs[0].
+func (a *Elasticsearch) nextBaseURL() string {
+	idx := atomic.AddUint64(&a.urlIdx, 1)
+	return a.baseURLs[idx%uint64(len(a.baseURLs))]
+}
+
+// sendBulk issues a single "_bulk" call against the cluster using the
+// internal HTTP client, which is populated in Connect() regardless of
+// useRawClient.
+func (a *Elasticsearch) sendBulk(payload []byte) (*bulkRawResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.Timeout))
+	defer cancel()
+
+	body := payload
+	if a.EnableGzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(payload); err != nil {
+			return nil, fmt.Errorf("error gzip-compressing bulk request to Elasticsearch: %s", err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("error gzip-compressing bulk request to Elasticsearch: %s", err)
 		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.nextBaseURL()+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building bulk request to Elasticsearch: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if a.EnableGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if a.Username != "" && a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.AuthBearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.AuthBearerToken)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending bulk request to Elasticsearch: %s", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bulk response from Elasticsearch: %s", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &bulkHTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var result bulkRawResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("error decoding bulk response from Elasticsearch: %s", err)
+	}
+
+	return &result, nil
+}
+
+// isRetriableItemError reports whether a single failed bulk item is worth
+// resubmitting: es_rejected_execution_exception (the cluster's thread pool
+// was saturated) and HTTP 429 are transient backpressure, everything else
+// (mapper_parsing_exception, version conflicts, illegal_argument_exception,
+// ...) is a permanent rejection that retrying cannot fix.
+func isRetriableItemError(errType string, status int) bool {
+	if errType == "es_rejected_execution_exception" {
+		return true
+	}
+	return status == http.StatusTooManyRequests
+}
+
+// handleBulkResult walks a successful bulk response in lock-step with the
+// batch that produced it, since Elasticsearch returns one result per
+// submitted item in the same order. Every per-item failure is logged
+// (rate-limited) and counted; retriable ones are resubmitted up to
+// max_retries times, and anything that is not retriable or has exhausted its
+// retries is routed to dead_letter_index when one is configured.
+func (a *Elasticsearch) handleBulkResult(batch []bulkItem, result *bulkRawResponse) {
+	if !result.Errors {
+		return
+	}
+
+	maxRetries := a.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	idx := 0
+	for _, itemResult := range result.Items {
+		for _, res := range itemResult {
+			if idx >= len(batch) {
+				return
+			}
+			item := batch[idx]
+			idx++
+
+			if res.Error == nil {
+				continue
+			}
+
+			a.incrBulkFailure(res.Error.Type)
+			a.logBulkFailure(item, res.Status, res.Error.Type, res.Error.Reason)
+
+			if isRetriableItemError(res.Error.Type, res.Status) && item.retries < maxRetries {
+				item.retries++
+				// retryEnqueue, not processor.enqueue: this runs inside a
+				// worker goroutine spawned by flush(), and enqueue's bare
+				// send on bp.items would race stop() closing that channel.
+				// It only returns false once the processor is shutting
+				// down, in which case the item falls through to
+				// dead-letter handling below like any other exhausted retry.
+				if a.processor.retryEnqueue(item) {
+					continue
+				}
+			}
 
-		if a.UsePipeline != "" {
-			if pipelineName := a.getPipelineName(a.pipelineName, a.pipelineTagKeys, metric.Tags()); pipelineName != "" {
-				br.Pipeline(pipelineName)
+			if a.DeadLetterIndex != "" {
+				a.sendToDeadLetter(item, res.Status, res.Error.Type, res.Error.Reason)
 			}
 		}
+	}
+}
 
-		bulkRequest.Add(br)
+// incrBulkFailure increments the elasticsearch_bulk_failures_total counter
+// for the given error reason, registering it with selfstat on first use.
+func (a *Elasticsearch) incrBulkFailure(reason string) {
+	a.bulkFailureStatsMu.Lock()
+	defer a.bulkFailureStatsMu.Unlock()
+
+	if a.bulkFailureStats == nil {
+		a.bulkFailureStats = make(map[string]selfstat.Stat)
+	}
+	stat, ok := a.bulkFailureStats[reason]
+	if !ok {
+		stat = selfstat.Register("elasticsearch", "bulk_failures_total", map[string]string{"reason": reason})
+		a.bulkFailureStats[reason] = stat
+	}
+	stat.Incr(1)
+}
+
+// failureLogInterval bounds how often logBulkFailure actually writes a log
+// line, so a mapping error on a high-volume measurement doesn't flood the
+// log once per rejected document.
+const failureLogInterval = time.Second
+
+func (a *Elasticsearch) logBulkFailure(item bulkItem, status int, errType, reason string) {
+	a.failureLogMu.Lock()
+	now := time.Now()
+	if !a.lastFailureLog.IsZero() && now.Sub(a.lastFailureLog) < failureLogInterval {
+		a.suppressedFailureLogs++
+		a.failureLogMu.Unlock()
+		return
+	}
+	suppressed := a.suppressedFailureLogs
+	a.suppressedFailureLogs = 0
+	a.lastFailureLog = now
+	a.failureLogMu.Unlock()
+
+	if suppressed > 0 {
+		a.Log.Errorf("Elasticsearch suppressed %d additional indexing failure log(s) in the last %s", suppressed, failureLogInterval)
+	}
+	a.Log.Errorf("Elasticsearch indexing failure, metric: %s, index: %s, status: %d, type: %s, reason: %s", item.metricName, item.indexName, status, errType, reason)
+}
+
+// deadLetterDoc is the body written to dead_letter_index for a bulk item
+// that could not be indexed, carrying the original document alongside the
+// error that rejected it.
+type deadLetterDoc struct {
+	Timestamp   time.Time       `json:"@timestamp"`
+	MetricName  string          `json:"metric_name"`
+	SourceIndex string          `json:"source_index"`
+	ErrorStatus int             `json:"error_status"`
+	ErrorType   string          `json:"error_type"`
+	ErrorReason string          `json:"error_reason"`
+	Document    json.RawMessage `json:"document"`
+}
+
+// sendToDeadLetter writes a single rejected item to dead_letter_index. This
+// is a best-effort side channel: a failure here is logged but otherwise
+// swallowed, since it must not re-enter the bulk processor and risk looping.
+func (a *Elasticsearch) sendToDeadLetter(item bulkItem, status int, errType, reason string) {
+	now := time.Now()
+	indexName := a.GetIndexName(a.DeadLetterIndex, now, nil, nil)
+
+	body, err := json.Marshal(deadLetterDoc{
+		Timestamp:   now,
+		MetricName:  item.metricName,
+		SourceIndex: item.indexName,
+		ErrorStatus: status,
+		ErrorType:   errType,
+		ErrorReason: reason,
+		Document:    json.RawMessage(item.doc),
+	})
+	if err != nil {
+		a.Log.Errorf("Elasticsearch failed to marshal dead-letter document for metric %s: %s", item.metricName, err)
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.Timeout))
 	defer cancel()
 
-	res, err := bulkRequest.Do(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.nextBaseURL()+"/"+indexName+"/_doc", bytes.NewReader(body))
+	if err != nil {
+		a.Log.Errorf("Elasticsearch failed to build dead-letter request for metric %s: %s", item.metricName, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.Username != "" && a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.AuthBearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.AuthBearerToken)
+	}
 
+	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending bulk request to Elasticsearch: %s", err)
+		a.Log.Errorf("Elasticsearch failed to write dead-letter document for metric %s: %s", item.metricName, err)
+		return
 	}
+	defer resp.Body.Close()
 
-	if res.Errors {
-		for id, err := range res.Failed() {
-			a.Log.Errorf("Elasticsearch indexing failure, id: %d, error: %s, caused by: %s, %s", id, err.Error.Reason, err.Error.CausedBy["reason"], err.Error.CausedBy["type"])
-			break
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		a.Log.Errorf("Elasticsearch failed to write dead-letter document for metric %s to index %s: status %d: %s", item.metricName, indexName, resp.StatusCode, string(respBody))
+	}
+}
+
+// buildDoc assembles the JSON document body for a single metric for the raw
+// HTTP bulk path (marshalBulkItem).
+func (a *Elasticsearch) buildDoc(metric telegraf.Metric) map[string]interface{} {
+	name := metric.Name()
+
+	// Handle NaN and inf field-values
+	fields := make(map[string]interface{})
+	for k, value := range metric.Fields() {
+		v, ok := value.(float64)
+		if !ok || a.FloatHandling == "none" || !(math.IsNaN(v) || math.IsInf(v, 0)) {
+			fields[k] = value
+			continue
+		}
+		if a.FloatHandling == "drop" {
+			continue
+		}
+
+		if math.IsNaN(v) || math.IsInf(v, 1) {
+			fields[k] = a.FloatReplacement
+		} else {
+			fields[k] = -a.FloatReplacement
 		}
-		return fmt.Errorf("elasticsearch failed to index %d metrics", len(res.Failed()))
 	}
 
-	return nil
+	m := make(map[string]interface{})
+
+	m["@timestamp"] = metric.Time()
+	m["measurement_name"] = name
+
+	if a.FlattenFields {
+		sep := a.flattenSeparator()
+		for k, v := range metric.Tags() {
+			m["tag"+sep+k] = v
+		}
+		for k, v := range fields {
+			m[name+sep+k] = v
+		}
+	} else {
+		m["tag"] = metric.Tags()
+		m[name] = fields
+	}
+
+	return m
+}
+
+// flattenSeparator returns the configured FlattenSeparator, defaulting to
+// "." when unset.
+func (a *Elasticsearch) flattenSeparator() string {
+	if a.FlattenSeparator == "" {
+		return "."
+	}
+	return a.FlattenSeparator
+}
+
+// flattenDynamicTemplates renders the extra dynamic_templates entry needed
+// so flattened tags still map to keyword, for insertion via a template's
+// ExtraDynamicTemplates field. The existing metrics_long/metrics_double
+// entries already match by type regardless of path, so flattened numeric
+// fields need no equivalent addition. It returns "" when flatten_fields is
+// disabled or flatten_separator is the default ".", since the existing
+// "tags" entry's "tag.*" path_match already covers that case.
+func (a *Elasticsearch) flattenDynamicTemplates() string {
+	sep := a.flattenSeparator()
+	if !a.FlattenFields || sep == "." {
+		return ""
+	}
+
+	return fmt.Sprintf(`,
+			{
+				"flattened_tags": {
+					"match": "tag%s*",
+					"match_mapping_type": "string",
+					"mapping": {
+						"ignore_above": 512,
+						"type": "keyword"
+					}
+				}
+			}`, sep)
+}
+
+// bulkRawResponse models the subset of the Elasticsearch Bulk API response
+// body needed to detect and report per-item failures.
+type bulkRawResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
 }
 
 func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
@@ -430,8 +1311,9 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 
 	if (a.OverwriteTemplate) || (!templateExists) || (templatePattern != "") {
 		tp := templatePart{
-			TemplatePattern: templatePattern + "*",
-			Version:         a.majorReleaseNumber,
+			TemplatePattern:       templatePattern + "*",
+			Version:               a.majorReleaseNumber,
+			ExtraDynamicTemplates: a.flattenDynamicTemplates(),
 		}
 
 		t := template.Must(template.New("template").Parse(telegrafTemplate))
@@ -453,6 +1335,496 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 	return nil
 }
 
+// rawComposableTemplate is the body of a composable index template
+// (ES/OpenSearch 7.8+), used for clusters at or above minRawClientVersion
+// where the legacy "_template" API is no longer available.
+const rawComposableTemplate = `
+{
+	"index_patterns": [ "{{.TemplatePattern}}" ],
+	"template": {
+		"settings": {
+			"index": {
+				"refresh_interval": "10s",
+				"mapping.total_fields.limit": 5000,
+				"auto_expand_replicas": "0-1",
+				"codec": "best_compression"
+			}
+		},
+		"mappings": {
+			"properties": {
+				"@timestamp": { "type": "date" },
+				"measurement_name": { "type": "keyword" }
+			},
+			"dynamic_templates": [
+				{
+					"tags": {
+						"match_mapping_type": "string",
+						"path_match": "tag.*",
+						"mapping": {
+							"ignore_above": 512,
+							"type": "keyword"
+						}
+					}
+				},
+				{
+					"metrics_long": {
+						"match_mapping_type": "long",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				},
+				{
+					"metrics_double": {
+						"match_mapping_type": "double",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				},
+				{
+					"text_fields": {
+						"match": "*",
+						"mapping": {
+							"norms": false
+						}
+					}
+				}
+				{{.ExtraDynamicTemplates}}
+			]
+		}
+	}
+}`
+
+// manageTemplateRaw installs a composable index template via the internal
+// HTTP client, for clusters at or above minRawClientVersion.
+func (a *Elasticsearch) manageTemplateRaw(ctx context.Context) error {
+	if a.TemplateName == "" {
+		return fmt.Errorf("elasticsearch template_name configuration not defined")
+	}
+
+	templatePattern := a.IndexName
+
+	if strings.Contains(templatePattern, "%") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "%")]
+	}
+
+	if strings.Contains(templatePattern, "{{") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "{{")]
+	}
+
+	if templatePattern == "" {
+		return fmt.Errorf("template cannot be created for dynamic index names without an index prefix")
+	}
+
+	existsReq, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/_index_template/"+a.TemplateName, nil)
+	if err != nil {
+		return fmt.Errorf("elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, err)
+	}
+	existsResp, err := a.httpClient.Do(existsReq)
+	if err != nil {
+		return fmt.Errorf("elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, err)
+	}
+	existsResp.Body.Close()
+	templateExists := existsResp.StatusCode == http.StatusOK
+
+	if !a.OverwriteTemplate && templateExists {
+		a.Log.Debug("Found existing Elasticsearch template. Skipping template management")
+		return nil
+	}
+
+	tp := templatePart{
+		TemplatePattern:       templatePattern + "*",
+		Version:               a.majorReleaseNumber,
+		ExtraDynamicTemplates: a.flattenDynamicTemplates(),
+	}
+
+	t := template.Must(template.New("template").Parse(rawComposableTemplate))
+	var tmpl bytes.Buffer
+	if err := t.Execute(&tmpl, tp); err != nil {
+		return err
+	}
+
+	putReq, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/_index_template/"+a.TemplateName, &tmpl)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	putReq.Header.Set("Content-Type", "application/json")
+
+	putResp, err := a.httpClient.Do(putReq)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	defer putResp.Body.Close()
+
+	if putResp.StatusCode < 200 || putResp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(putResp.Body)
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, string(respBody))
+	}
+
+	a.Log.Debugf("Template %s created or updated\n", a.TemplateName)
+	return nil
+}
+
+// mappingsComponentTemplate and settingsComponentTemplate are the two
+// pieces telegraf's managed template is assembled from when
+// template_type = "component". Keeping them separate lets a user override
+// just one of them by supplying their own component_templates and letting
+// telegraf's components compose on top.
+const mappingsComponentTemplate = `
+{
+	"template": {
+		"mappings": {
+			"properties": {
+				"@timestamp": { "type": "date" },
+				"measurement_name": { "type": "keyword" }
+			},
+			"dynamic_templates": [
+				{
+					"tags": {
+						"match_mapping_type": "string",
+						"path_match": "tag.*",
+						"mapping": {
+							"ignore_above": 512,
+							"type": "keyword"
+						}
+					}
+				},
+				{
+					"metrics_long": {
+						"match_mapping_type": "long",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				},
+				{
+					"metrics_double": {
+						"match_mapping_type": "double",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				},
+				{
+					"text_fields": {
+						"match": "*",
+						"mapping": {
+							"norms": false
+						}
+					}
+				}
+				{{.ExtraDynamicTemplates}}
+			]
+		}
+	}
+}`
+
+const settingsComponentTemplate = `
+{
+	"template": {
+		"settings": {
+			"index": {
+				"refresh_interval": "10s",
+				"mapping.total_fields.limit": 5000,
+				"auto_expand_replicas": "0-1",
+				"codec": "best_compression"
+			}
+		}
+	}
+}`
+
+// manageComponentTemplates installs telegraf's mappings and settings as
+// separate component templates and composes them, together with any
+// user-supplied ComponentTemplates, into the final index template.
+func (a *Elasticsearch) manageComponentTemplates(ctx context.Context) error {
+	if a.TemplateName == "" {
+		return fmt.Errorf("elasticsearch template_name configuration not defined")
+	}
+
+	templatePattern := a.IndexName
+
+	if strings.Contains(templatePattern, "%") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "%")]
+	}
+
+	if strings.Contains(templatePattern, "{{") {
+		templatePattern = templatePattern[0:strings.Index(templatePattern, "{{")]
+	}
+
+	if templatePattern == "" {
+		return fmt.Errorf("template cannot be created for dynamic index names without an index prefix")
+	}
+
+	mappingsName := a.TemplateName + "-mappings"
+	settingsName := a.TemplateName + "-settings"
+
+	mt := template.Must(template.New("mappingsComponent").Parse(mappingsComponentTemplate))
+	var mappingsBody bytes.Buffer
+	if err := mt.Execute(&mappingsBody, struct{ ExtraDynamicTemplates string }{a.flattenDynamicTemplates()}); err != nil {
+		return err
+	}
+
+	if err := a.putComponentTemplate(ctx, mappingsName, mappingsBody.String()); err != nil {
+		return err
+	}
+	if err := a.putComponentTemplate(ctx, settingsName, settingsComponentTemplate); err != nil {
+		return err
+	}
+
+	// User-supplied components are composed first so that telegraf's own
+	// mappings/settings components layer on top of them.
+	composedOf := append(append([]string{}, a.ComponentTemplates...), settingsName, mappingsName)
+
+	body := struct {
+		IndexPatterns []string `json:"index_patterns"`
+		ComposedOf    []string `json:"composed_of"`
+	}{
+		IndexPatterns: []string{templatePattern + "*"},
+		ComposedOf:    composedOf,
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/_index_template/"+a.TemplateName, bytes.NewReader(bodyJSON))
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, string(respBody))
+	}
+
+	a.Log.Debugf("Template %s created or updated from components %v\n", a.TemplateName, composedOf)
+	return nil
+}
+
+func (a *Elasticsearch) putComponentTemplate(ctx context.Context, name, body string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/_component_template/"+name, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create component template %s : %s", name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create component template %s : %s", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("elasticsearch failed to create component template %s : %s", name, string(respBody))
+	}
+
+	a.Log.Debugf("Component template %s created or updated\n", name)
+	return nil
+}
+
+// defaultILMPolicy is a minimal hot/delete lifecycle: rollover the backing
+// index once it reaches max_age or max_size, and delete it once the oldest
+// document in it reaches min_age_delete.
+const defaultILMPolicy = `
+{
+	"policy": {
+		"phases": {
+			"hot": {
+				"actions": {
+					"rollover": {
+						"max_age": "{{.MaxAge}}",
+						"max_size": "{{.MaxSize}}"
+					}
+				}
+			},
+			"delete": {
+				"min_age": "{{.MinAgeDelete}}",
+				"actions": {
+					"delete": {}
+				}
+			}
+		}
+	}
+}`
+
+type ilmPolicyPart struct {
+	MaxAge       string
+	MaxSize      string
+	MinAgeDelete string
+}
+
+// dataStreamTemplate is the composable index template installed for
+// use_data_stream = true: it marks the target as a data stream with
+// "@timestamp" as the timestamp field and attaches the ILM policy via
+// "index.lifecycle.name".
+const dataStreamTemplate = `
+{
+	"index_patterns": [ "{{.TemplatePattern}}" ],
+	"data_stream": {},
+	"template": {
+		"settings": {
+			"index": {
+				"mapping.total_fields.limit": 5000,
+				"codec": "best_compression",
+				"lifecycle.name": "{{.ILMPolicyName}}"
+			}
+		},
+		"mappings": {
+			"properties": {
+				"@timestamp": { "type": "date" },
+				"measurement_name": { "type": "keyword" }
+			},
+			"dynamic_templates": [
+				{
+					"tags": {
+						"match_mapping_type": "string",
+						"path_match": "tag.*",
+						"mapping": {
+							"ignore_above": 512,
+							"type": "keyword"
+						}
+					}
+				},
+				{
+					"metrics_long": {
+						"match_mapping_type": "long",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				},
+				{
+					"metrics_double": {
+						"match_mapping_type": "double",
+						"mapping": {
+							"type": "float",
+							"index": false
+						}
+					}
+				}
+				{{.ExtraDynamicTemplates}}
+			]
+		}
+	}
+}`
+
+type dataStreamTemplatePart struct {
+	TemplatePattern       string
+	ILMPolicyName         string
+	ExtraDynamicTemplates string
+}
+
+// manageDataStream installs the ILM policy and the composable index
+// template that backs a data stream, both over the raw HTTP client since
+// neither API has an equivalent in the bundled github.com/olivere/elastic
+// client.
+func (a *Elasticsearch) manageDataStream(ctx context.Context) error {
+	if a.TemplateName == "" {
+		return fmt.Errorf("elasticsearch template_name configuration not defined")
+	}
+	if a.ILMPolicyName == "" {
+		return fmt.Errorf("elasticsearch ilm_policy_name configuration not defined")
+	}
+
+	policyBody, err := a.buildILMPolicy()
+	if err != nil {
+		return err
+	}
+
+	policyReq, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/_ilm/policy/"+a.ILMPolicyName, bytes.NewReader(policyBody))
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to build ilm policy request %s : %s", a.ILMPolicyName, err)
+	}
+	policyReq.Header.Set("Content-Type", "application/json")
+
+	policyResp, err := a.httpClient.Do(policyReq)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create ilm policy %s : %s", a.ILMPolicyName, err)
+	}
+	defer policyResp.Body.Close()
+	if policyResp.StatusCode < 200 || policyResp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(policyResp.Body)
+		return fmt.Errorf("elasticsearch failed to create ilm policy %s : %s", a.ILMPolicyName, string(respBody))
+	}
+	a.Log.Debugf("ILM policy %s created or updated\n", a.ILMPolicyName)
+
+	tp := dataStreamTemplatePart{
+		TemplatePattern:       a.IndexName + "*",
+		ILMPolicyName:         a.ILMPolicyName,
+		ExtraDynamicTemplates: a.flattenDynamicTemplates(),
+	}
+
+	t := template.Must(template.New("template").Parse(dataStreamTemplate))
+	var tmpl bytes.Buffer
+	if err := t.Execute(&tmpl, tp); err != nil {
+		return err
+	}
+
+	templateReq, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/_index_template/"+a.TemplateName, &tmpl)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	templateReq.Header.Set("Content-Type", "application/json")
+
+	templateResp, err := a.httpClient.Do(templateReq)
+	if err != nil {
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, err)
+	}
+	defer templateResp.Body.Close()
+	if templateResp.StatusCode < 200 || templateResp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(templateResp.Body)
+		return fmt.Errorf("elasticsearch failed to create index template %s : %s", a.TemplateName, string(respBody))
+	}
+	a.Log.Debugf("Template %s created or updated\n", a.TemplateName)
+
+	return nil
+}
+
+// buildILMPolicy returns the configured ILMPolicy verbatim (reading it from
+// disk first if it names an existing file), or renders a default hot/delete
+// policy from the ILMMaxAge/ILMMaxSize/ILMMinAgeDelete settings.
+func (a *Elasticsearch) buildILMPolicy() ([]byte, error) {
+	if a.ILMPolicy != "" {
+		if contents, err := os.ReadFile(a.ILMPolicy); err == nil {
+			return contents, nil
+		}
+		return []byte(a.ILMPolicy), nil
+	}
+
+	maxAge := a.ILMMaxAge
+	if maxAge == "" {
+		maxAge = "30d"
+	}
+	maxSize := a.ILMMaxSize
+	if maxSize == "" {
+		maxSize = "50gb"
+	}
+	minAgeDelete := a.ILMMinAgeDelete
+	if minAgeDelete == "" {
+		minAgeDelete = "90d"
+	}
+
+	t := template.Must(template.New("ilmPolicy").Parse(defaultILMPolicy))
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ilmPolicyPart{MaxAge: maxAge, MaxSize: maxSize, MinAgeDelete: minAgeDelete}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func (a *Elasticsearch) GetTagKeys(indexName string) (string, []string) {
 	tagKeys := []string{}
 	startTag := strings.Index(indexName, "{{")
@@ -539,6 +1911,9 @@ func (a *Elasticsearch) Description() string {
 }
 
 func (a *Elasticsearch) Close() error {
+	if a.processor != nil {
+		a.processor.stop()
+	}
 	a.Client = nil
 	return nil
 }
@@ -549,6 +1924,11 @@ func init() {
 			Timeout:             config.Duration(time.Second * 5),
 			HealthCheckInterval: config.Duration(time.Second * 10),
 			HealthCheckTimeout:  config.Duration(time.Second * 1),
+			ILMPolicyName:       "telegraf",
+			BulkActions:         defaultBulkActions,
+			FlushInterval:       config.Duration(defaultFlushInterval),
+			Workers:             defaultWorkers,
+			MaxRetries:          defaultMaxRetries,
 		}
 	})
 }
@@ -0,0 +1,349 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLogger is a minimal telegraf.Logger that discards everything; these
+// tests care about behavior, not log output.
+type testLogger struct{}
+
+func (testLogger) Error(...interface{})          {}
+func (testLogger) Errorf(string, ...interface{}) {}
+func (testLogger) Debug(...interface{})          {}
+func (testLogger) Debugf(string, ...interface{}) {}
+func (testLogger) Warn(...interface{})           {}
+func (testLogger) Warnf(string, ...interface{})  {}
+func (testLogger) Info(...interface{})           {}
+func (testLogger) Infof(string, ...interface{})  {}
+
+// newTestElasticsearch builds an Elasticsearch pointed at srv, wired up the
+// same way Connect() would (minus the version-detection round trip), so the
+// bulk processor, sendBulk and sendToDeadLetter can be exercised directly.
+func newTestElasticsearch(srv *httptest.Server, maxRetries int) *Elasticsearch {
+	a := &Elasticsearch{
+		Log:         testLogger{},
+		URLs:        []string{srv.URL},
+		IndexName:   "telegraf-test",
+		BulkActions: 1,
+		Workers:     1,
+		MaxRetries:  maxRetries,
+		httpClient:  srv.Client(),
+		baseURLs:    []string{srv.URL},
+		baseURL:     srv.URL,
+	}
+	a.processor = newBulkProcessor(a)
+	return a
+}
+
+func testItem(name string) bulkItem {
+	return bulkItem{
+		lines:      []byte(`{"index":{"_index":"telegraf-test"}}` + "\n" + `{"x":1}` + "\n"),
+		doc:        []byte(`{"x":1}`),
+		metricName: name,
+		indexName:  "telegraf-test",
+	}
+}
+
+// TestBulkProcessorStopDuringRetryDoesNotPanic is a regression test for a
+// shutdown race: handleBulkResult used to resubmit a retriable per-item
+// failure with a bare send on the ingest channel (bp.items <- item), which
+// panicked if it raced stop() closing that channel. retryEnqueue must be
+// safe to call concurrently with stop(), even while a batch is in flight.
+func TestBulkProcessorStopDuringRetryDoesNotPanic(t *testing.T) {
+	received := make(chan struct{})
+	proceed := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-proceed
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"errors": true,
+			"items": [
+				{"index": {"status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "busy"}}}
+			]
+		}`))
+	}))
+	defer srv.Close()
+
+	a := newTestElasticsearch(srv, 5)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("bulk processor panicked during shutdown: %v", r)
+			}
+		}()
+
+		a.processor.enqueue(testItem("cpu"))
+		<-received
+
+		stopDone := make(chan struct{})
+		go func() {
+			a.processor.stop()
+			close(stopDone)
+		}()
+		// Give stop() a moment to close done/items before the server
+		// response (and the retry it triggers) is allowed to proceed.
+		time.Sleep(20 * time.Millisecond)
+		close(proceed)
+		<-stopDone
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for bulk processor shutdown")
+	}
+}
+
+// TestHandleBulkResultRetriesThenSucceeds drives a real retriable item
+// failure followed by a clean response through sendBulkWithRetry and asserts
+// that the failed item is resubmitted in a second bulk request, which the
+// server then accepts.
+func TestHandleBulkResultRetriesThenSucceeds(t *testing.T) {
+	var attempts int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			_, _ = w.Write([]byte(`{
+				"errors": true,
+				"items": [
+					{"index": {"status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "busy"}}}
+				]
+			}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors": false, "items": [{"index": {"status": 201}}]}`))
+	}))
+	defer srv.Close()
+
+	a := newTestElasticsearch(srv, 5)
+	defer a.processor.stop()
+
+	a.processor.enqueue(testItem("cpu"))
+
+	deadline := time.After(5 * time.Second)
+	for atomic.LoadInt32(&attempts) < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected at least 2 bulk requests (original + retry), got %d", atomic.LoadInt32(&attempts))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestHandleBulkResultDeadLettersNonRetriable asserts that a non-retriable
+// per-item failure (e.g. a mapping error) is routed to dead_letter_index
+// with the original document and the Elasticsearch error attached, instead
+// of being retried forever.
+func TestHandleBulkResultDeadLettersNonRetriable(t *testing.T) {
+	var dlqBody []byte
+	var dlqMu sync.Mutex
+	dlqReceived := make(chan struct{})
+
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/telegraf-dlq/_doc" {
+			body, _ := io.ReadAll(r.Body)
+			dlqMu.Lock()
+			dlqBody = body
+			dlqMu.Unlock()
+			once.Do(func() { close(dlqReceived) })
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"errors": true,
+			"items": [
+				{"index": {"status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse field"}}}
+			]
+		}`))
+	}))
+	defer srv.Close()
+
+	a := newTestElasticsearch(srv, 3)
+	a.DeadLetterIndex = "telegraf-dlq"
+	defer a.processor.stop()
+
+	a.processor.enqueue(testItem("cpu"))
+
+	select {
+	case <-dlqReceived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for dead-letter write")
+	}
+
+	dlqMu.Lock()
+	defer dlqMu.Unlock()
+	var doc deadLetterDoc
+	if err := json.Unmarshal(dlqBody, &doc); err != nil {
+		t.Fatalf("dead-letter body is not valid JSON: %s: %q", err, dlqBody)
+	}
+	if doc.ErrorType != "mapper_parsing_exception" {
+		t.Errorf("expected dead-lettered error_type %q, got %q", "mapper_parsing_exception", doc.ErrorType)
+	}
+	if doc.MetricName != "cpu" {
+		t.Errorf("expected dead-lettered metric_name %q, got %q", "cpu", doc.MetricName)
+	}
+	if !bytes.Equal(doc.Document, []byte(`{"x":1}`)) {
+		t.Errorf("expected dead-lettered document %q, got %q", `{"x":1}`, doc.Document)
+	}
+}
+
+// TestNextBaseURLRoundRobins asserts that write-path requests are spread
+// across every configured URL rather than always landing on URLs[0].
+func TestNextBaseURLRoundRobins(t *testing.T) {
+	a := &Elasticsearch{baseURLs: []string{"http://node1:9200", "http://node2:9200"}}
+
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		seen[a.nextBaseURL()]++
+	}
+
+	if seen["http://node1:9200"] == 0 || seen["http://node2:9200"] == 0 {
+		t.Fatalf("expected requests spread across both urls, got %v", seen)
+	}
+}
+
+// TestItemRetryBackoffDoublesPerAttempt asserts that later per-item retry
+// attempts wait longer, mirroring sendBulkWithRetry's whole-batch backoff,
+// so a retriable item failure does not just ride the very next flush under
+// sustained backpressure.
+func TestItemRetryBackoffDoublesPerAttempt(t *testing.T) {
+	cases := []struct {
+		retries int
+		want    time.Duration
+	}{
+		{retries: 0, want: 0},
+		{retries: 1, want: initialRetryBackoff},
+		{retries: 2, want: 2 * initialRetryBackoff},
+		{retries: 3, want: 4 * initialRetryBackoff},
+	}
+
+	for _, c := range cases {
+		if got := itemRetryBackoff(c.retries); got != c.want {
+			t.Errorf("itemRetryBackoff(%d) = %s, want %s", c.retries, got, c.want)
+		}
+	}
+}
+
+// TestBulkProcessorRetryEnqueueHonorsBackoff asserts that a retried item is
+// not made available to be re-flushed until its backoff has elapsed.
+func TestBulkProcessorRetryEnqueueHonorsBackoff(t *testing.T) {
+	// run() drains the retry queue as soon as an item lands in it, so the
+	// retry is observed when its bulk request reaches the server.
+	sent := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sent <- struct{}{}:
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"errors": false, "items": [{"index": {"status": 201}}]}`))
+	}))
+	defer srv.Close()
+
+	a := newTestElasticsearch(srv, 5)
+	defer a.processor.stop()
+
+	item := testItem("cpu")
+	item.retries = 2 // itemRetryBackoff(2) == 2 * initialRetryBackoff
+
+	start := time.Now()
+	if !a.processor.retryEnqueue(item) {
+		t.Fatal("expected retryEnqueue to accept the item")
+	}
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the backed-off retry to be sent")
+	}
+
+	elapsed := time.Since(start)
+	wantMin := itemRetryBackoff(2)
+	if elapsed < wantMin {
+		t.Errorf("retry was queued after %s, expected at least %s of backoff", elapsed, wantMin)
+	}
+}
+
+// TestManageTemplateRawIncludesFlattenedTags asserts that the composable
+// index template installed for ES8+/OpenSearch (manageTemplateRaw) still
+// carries the flattened-tags dynamic template when flatten_fields is set
+// with a non-default separator, just like the legacy and component template
+// paths do.
+func TestManageTemplateRawIncludesFlattenedTags(t *testing.T) {
+	var putBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		putBody = body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a := &Elasticsearch{
+		Log:              testLogger{},
+		TemplateName:     "telegraf",
+		IndexName:        "telegraf-%Y.%m.%d",
+		FlattenFields:    true,
+		FlattenSeparator: "_",
+		httpClient:       srv.Client(),
+		baseURL:          srv.URL,
+	}
+
+	if err := a.manageTemplateRaw(context.Background()); err != nil {
+		t.Fatalf("manageTemplateRaw failed: %s", err)
+	}
+
+	if !bytes.Contains(putBody, []byte("flattened_tags")) {
+		t.Errorf("expected composable index template to contain a flattened_tags dynamic template, got: %s", putBody)
+	}
+	if !bytes.Contains(putBody, []byte(`"match": "tag_*"`)) {
+		t.Errorf("expected flattened_tags dynamic template to match \"tag_*\", got: %s", putBody)
+	}
+}
+
+// TestDetectMajorReleaseNumberOpenSearch asserts that an OpenSearch cluster
+// reporting a pre-5.x version number (as OpenSearch 1.x/2.x do) is still
+// accepted, with its distribution surfaced so Connect() can force the raw
+// client rather than handing it to github.com/olivere/elastic.
+func TestDetectMajorReleaseNumberOpenSearch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": {"number": "2.11.0", "distribution": "opensearch"}}`))
+	}))
+	defer srv.Close()
+
+	a := &Elasticsearch{Log: testLogger{}, URLs: []string{srv.URL}}
+
+	major, isOpenSearch, err := a.detectMajorReleaseNumber(context.Background(), srv.Client())
+	if err != nil {
+		t.Fatalf("expected OpenSearch 2.x to be accepted, got error: %s", err)
+	}
+	if !isOpenSearch {
+		t.Error("expected isOpenSearch to be true for a cluster reporting distribution \"opensearch\"")
+	}
+	if major != 2 {
+		t.Errorf("expected major release number 2, got %d", major)
+	}
+}